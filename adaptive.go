@@ -0,0 +1,159 @@
+package dynaphore
+
+import (
+	"sync"
+	"time"
+)
+
+// Watcher reports whether the resource or signal it observes is currently healthy. Adaptive samples every
+// registered Watcher on each tick of its control loop to decide whether to grow or shrink its max. Typical watchers
+// might sample CPU usage, memory usage, a downstream error rate, or request latency.
+type Watcher interface {
+	Sample() (healthy bool)
+}
+
+// ChangeFunc is invoked whenever Adaptive changes its underlying max, naming the watcher that triggered the change.
+// watcher is empty when the change was an additive increase triggered by all watchers reporting healthy.
+type ChangeFunc func(old, new int, watcher string)
+
+// Option configures an Adaptive at construction time.
+type Option func(*Adaptive)
+
+// WithWatcher registers a named Watcher that Adaptive samples on every tick. The name is passed to ChangeFunc when
+// this watcher is the one that triggers a multiplicative decrease.
+func WithWatcher(name string, w Watcher) Option {
+	return func(a *Adaptive) {
+		a.watchers = append(a.watchers, namedWatcher{name: name, watcher: w})
+	}
+}
+
+// WithInterval sets how often Adaptive samples its watchers and reconsiders its max. It defaults to one second.
+func WithInterval(interval time.Duration) Option {
+	return func(a *Adaptive) {
+		a.interval = interval
+	}
+}
+
+// WithBackoffFactor sets the multiplicative factor applied to the current max when a watcher reports unhealthy. It
+// defaults to 0.5, halving the max on every unhealthy tick.
+func WithBackoffFactor(factor float64) Option {
+	return func(a *Adaptive) {
+		a.backoffFactor = factor
+	}
+}
+
+// WithOnChange registers a callback invoked with the old and new max every time Adaptive changes it.
+func WithOnChange(fn ChangeFunc) Option {
+	return func(a *Adaptive) {
+		a.onChange = fn
+	}
+}
+
+type namedWatcher struct {
+	name    string
+	watcher Watcher
+}
+
+// Adaptive wraps a Dynaphore and continuously retunes its max permitted concurrency with an AIMD control loop: while
+// every registered Watcher reports healthy, the max grows by one permit per tick; as soon as any Watcher reports
+// unhealthy, the max is cut by backoffFactor. Every change is applied through the wrapped Dynaphore's existing
+// SetMax, so in-flight holders are never disturbed.
+type Adaptive struct {
+	Dynaphore
+
+	min, max      int
+	current       int
+	interval      time.Duration
+	backoffFactor float64
+	watchers      []namedWatcher
+	onChange      ChangeFunc
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewAdaptive creates an Adaptive starting at initial permits, never growing past max or shrinking below min.
+// Watchers and tuning knobs are supplied via Option, e.g. WithWatcher, WithInterval, WithBackoffFactor.
+func NewAdaptive(initial, min, max int, opts ...Option) *Adaptive {
+	a := &Adaptive{
+		Dynaphore:     NewDynaphore(initial),
+		min:           min,
+		max:           max,
+		current:       initial,
+		interval:      time.Second,
+		backoffFactor: 0.5,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	go a.run()
+
+	return a
+}
+
+// Close stops Adaptive's background calculator and closes the wrapped Dynaphore. Close is idempotent and safe to
+// call more than once.
+func (a *Adaptive) Close() error {
+	a.closeOnce.Do(func() {
+		close(a.stop)
+		<-a.done
+	})
+	return a.Dynaphore.Close()
+}
+
+func (a *Adaptive) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.tick()
+		}
+	}
+}
+
+func (a *Adaptive) tick() {
+	healthy := true
+	trigger := ""
+	for _, nw := range a.watchers {
+		if !nw.watcher.Sample() {
+			healthy = false
+			trigger = nw.name
+			break
+		}
+	}
+
+	old := a.current
+	newMax := old
+	if healthy {
+		newMax = old + 1
+		if newMax > a.max {
+			newMax = a.max
+		}
+	} else {
+		newMax = int(float64(old) * a.backoffFactor)
+		if newMax < a.min {
+			newMax = a.min
+		}
+	}
+
+	if newMax == old {
+		return
+	}
+
+	a.current = newMax
+	a.Dynaphore.SetMax(newMax)
+	if a.onChange != nil {
+		a.onChange(old, newMax, trigger)
+	}
+}
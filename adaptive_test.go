@@ -0,0 +1,81 @@
+package dynaphore
+
+import (
+	"testing"
+	"time"
+)
+
+// boolWatcher reports a fixed health value, and can be flipped mid-test.
+type boolWatcher struct {
+	healthy bool
+}
+
+func (w *boolWatcher) Sample() bool {
+	return w.healthy
+}
+
+func TestAdaptive_GrowsWhenHealthy(t *testing.T) {
+	watcher := &boolWatcher{healthy: true}
+	adaptive := NewAdaptive(1, 1, 5, WithWatcher("test", watcher), WithInterval(10*time.Millisecond))
+	defer adaptive.Close()
+
+	time.Sleep(55 * time.Millisecond) // several ticks, should grow toward max but not past it
+
+	if !adaptive.TryAcquire() {
+		t.Fatalf("First TryAcquire unexpectedly failed.")
+	}
+
+	acquired := 1
+	for adaptive.TryAcquire() {
+		acquired++
+		if acquired > 5 {
+			t.Fatalf("Adaptive grew past its configured max of 5.")
+		}
+	}
+
+	if acquired < 2 {
+		t.Fatalf("Expected max to have grown above its initial value of 1, got %d acquired permits.", acquired)
+	}
+}
+
+func TestAdaptive_ShrinksWhenUnhealthy(t *testing.T) {
+	watcher := &boolWatcher{healthy: false}
+
+	oldNew := make(chan [2]int, 8)
+	adaptive := NewAdaptive(4, 1, 4, WithWatcher("test", watcher), WithInterval(10*time.Millisecond),
+		WithBackoffFactor(0.5), WithOnChange(func(old, new int, name string) {
+			oldNew <- [2]int{old, new}
+		}))
+	defer adaptive.Close()
+
+	select {
+	case change := <-oldNew:
+		if change[0] != 4 || change[1] != 2 {
+			t.Fatalf("Expected max to back off from 4 to 2, got %d -> %d", change[0], change[1])
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatalf("Expected an onChange callback after an unhealthy tick, got none.")
+	}
+}
+
+func TestAdaptive_Close(t *testing.T) {
+	watcher := &boolWatcher{healthy: true}
+	adaptive := NewAdaptive(1, 1, 5, WithWatcher("test", watcher), WithInterval(5*time.Millisecond))
+
+	time.Sleep(20 * time.Millisecond)
+	adaptive.Close()
+
+	// Close also closes the wrapped Dynaphore, so from here on TryAcquire fails whatever max was reached.
+	for adaptive.TryAcquire() {
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	acquired := 0
+	for adaptive.TryAcquire() {
+		acquired++
+	}
+	// 20ms after Close the closed Dynaphore must still grant no permits.
+	if acquired != 0 {
+		t.Fatalf("Expected no permits after Close, but acquired %d.", acquired)
+	}
+}
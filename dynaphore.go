@@ -1,5 +1,18 @@
 package dynaphore
 
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed is returned by Acquire and AcquireN once the Dynaphore has been closed; TryAcquire returns false instead.
+// SetMax, Up(N), and
+// UpChan become no-ops after close, and Down(N)/DownChan are tolerated no-ops, since there is nothing left to
+// coordinate with once the manager has shut down.
+var ErrClosed = errors.New("dynaphore: closed")
+
 // A Dynaphore is a dynamically-sized semaphore.
 type Dynaphore interface {
 	// SetMax can be used to increase or decrease the maximum permitted concurrent locks granted by the Dynaphore.
@@ -31,86 +44,404 @@ type Dynaphore interface {
 
 	// DownChan is the companion of UpChan().  If UpChan() is called, but the caller abandons waiting for the lock
 	// (for example, due to timeout), DownChan() should be called with the channel UpChan() returned.  DownChan will
-	// then wait for the LockChan to be closed, then call Down, removing the need for the Caller to do so.
+	// then cancel the pending acquisition if it hasn't been granted yet, consuming no permit; if it has already been
+	// granted, DownChan behaves exactly like Down().
 	// DownChan can also be used unconditionally as the "Down()" version of an UpChan() call.  Calls to DownChan() after
 	// UpChan has acquired its lock are semantically identical to calling Down().
 	DownChan(lockCh LockChan)
+
+	// Acquire acquires a lock, blocking until one is available or ctx is done. On success, it returns nil and the
+	// caller owns a lock that must later be released via Down(). On failure, it returns ctx.Err(), or ErrClosed if
+	// the Dynaphore has been closed, and no lock is held. Acquire mirrors the behavior of golang.org/x/sync/semaphore.Weighted.Acquire.
+	//
+	// If ctx is already done, Acquire may still succeed without blocking.
+	Acquire(ctx context.Context) error
+
+	// TryAcquire acquires a lock without blocking. On success, it returns true and the caller owns a lock that must
+	// later be released via Down(). On failure, it returns false and no lock is held.
+	TryAcquire() bool
+
+	// UpN is the weighted version of Up(). It attempts to acquire n locks atomically, blocking until the current
+	// number of active locks plus n fits within the set maximum. If n is greater than the maximum at the time of the
+	// call, UpN acquires nothing and blocks until Close, even if the maximum is raised in the meantime.
+	UpN(n int) Dynaphore
+
+	// DownN is the weighted version of Down(). It releases n previously acquired locks; n should match a previous
+	// UpN(n), AcquireN(ctx, n), or Up()/Acquire() calls.
+	DownN(n int) Dynaphore
+
+	// AcquireN is the weighted version of Acquire(ctx). It blocks until n locks can be acquired atomically or ctx is
+	// done. If n is greater than the Dynaphore's max, AcquireN can never succeed and will block until ctx is done,
+	// mirroring golang.org/x/sync/semaphore.Weighted.Acquire.
+	//
+	// Waiters are served in FIFO order: a pending AcquireN(ctx, n) that does not yet fit blocks all waiters behind
+	// it, so that a steady stream of small acquisitions cannot starve a larger one.
+	AcquireN(ctx context.Context, n int) error
+
+	// Close shuts down the Dynaphore's manager goroutine, unblocking every pending Acquire/AcquireN waiter with
+	// ErrClosed. After Close returns, SetMax and Up(N) become no-ops, Acquire/AcquireN/TryAcquire return
+	// ErrClosed/false, and Down(N)/DownChan are tolerated no-ops. Close is idempotent and safe to call more than
+	// once.
+	Close() error
+
+	// Max returns the current maximum number of concurrent locks.
+	Max() int
+
+	// Waiters returns the current number of callers blocked waiting to acquire a lock.
+	Waiters() int
+
+	// SetStatsHook registers hook to be called on every lock acquire, release, max change, and waiter enqueue/dequeue,
+	// with the resulting current, waiter, and max counts. hook is called from the Dynaphore's manager goroutine, so
+	// it must not call back into the same Dynaphore, and should return quickly. Passing nil disables the hook.
+	SetStatsHook(hook StatsHook) Dynaphore
 }
 
+// StatsEvent identifies the kind of change a StatsHook is being notified about.
+type StatsEvent int
+
+const (
+	// EventAcquire fires when a lock is granted, whether immediately or after waiting in the queue.
+	EventAcquire StatsEvent = iota
+	// EventRelease fires when a lock is released.
+	EventRelease
+	// EventMaxChange fires when the maximum is changed via SetMax.
+	EventMaxChange
+	// EventWaiterEnqueue fires when a caller is queued because a lock could not be granted immediately.
+	EventWaiterEnqueue
+	// EventWaiterDequeue fires when a queued caller leaves the queue, whether granted or cancelled.
+	EventWaiterDequeue
+)
+
+// StatsHook is invoked by a Dynaphore's manager goroutine whenever current, waiters, or max changes, reporting the
+// resulting counts. See Dynaphore.SetStatsHook.
+type StatsHook func(event StatsEvent, current, waiters, max int)
+
 type LockChan <-chan struct{}
 
+// waiter represents a single pending or granted lock request for n permits. ready is closed by the manager once the
+// request has been granted, or once the manager has shut down without granting it. granted distinguishes the two:
+// it is only ever set before ready is closed, so it's safe for the waiter's owner to read once ready is observed
+// closed. Acquire/AcquireN use it to report ErrClosed instead of a phantom grant; UpChan has no way to surface that
+// distinction to its caller and so intentionally does not check it, per ErrClosed's doc comment.
+type waiter struct {
+	n       int
+	ready   chan struct{}
+	granted bool
+}
+
+// tryRequest represents a non-blocking acquisition attempt for n permits; the manager replies on result without
+// ever queuing it.
+type tryRequest struct {
+	n      int
+	result chan bool
+}
+
 type dynaphore struct {
-	lock    chan struct{} // the dynaphore sends on this to gain a lock
-	unlock  chan struct{} // the dynaphore sends on this go release a lock
-	max     chan int      // the dynaphore sends on this to indicate that the maximum has changed
-	current chan int      // the dynyaphore receives on this when it wants to know the current number of locks
+	acquireCh   chan *waiter     // send a waiter here to request a lock
+	cancelCh    chan LockChan    // send a waiter's ready channel here to cancel it; no-op if already granted
+	downChanCh  chan LockChan    // send a waiter's ready channel here to release it, or cancel it if still pending
+	tryCh       chan *tryRequest // send a tryRequest here to attempt a non-blocking acquisition
+	downCh      chan int         // the dynaphore sends the number of locks to release on this
+	maxCh       chan int         // the dynaphore sends on this to indicate that the maximum has changed
+	maxReadCh   chan int         // the dynaphore receives on this when it wants to know the current maximum
+	currentCh   chan int         // the dynaphore receives on this when it wants to know the current number of locks
+	waitersCh   chan int         // the dynaphore receives on this when it wants to know the current number of waiters
+	statsHookCh chan StatsHook   // the dynaphore sends on this to install a new StatsHook
+
+	closeOnce sync.Once     // guards closeCh so Close() can be called more than once
+	closeCh   chan struct{} // closed once to signal the manager to shut down
+	closedCh  chan struct{} // closed by the manager once it has shut down
 }
 
 func NewDynaphore(max int) Dynaphore {
-	s := dynaphore{
-		lock:   make(chan struct{}),
-		unlock: make(chan struct{}, 1),
-		max:    make(chan int, 1),
+	s := &dynaphore{
+		acquireCh:   make(chan *waiter),
+		cancelCh:    make(chan LockChan),
+		downChanCh:  make(chan LockChan),
+		tryCh:       make(chan *tryRequest),
+		downCh:      make(chan int),
+		maxCh:       make(chan int, 1),
+		maxReadCh:   make(chan int),
+		currentCh:   make(chan int),
+		waitersCh:   make(chan int),
+		statsHookCh: make(chan StatsHook),
+		closeCh:     make(chan struct{}),
+		closedCh:    make(chan struct{}),
 	}
-	s.max <- max
+	s.maxCh <- max
 
 	go s.manager()
 
-	return &s
+	return s
 }
 
 func (s *dynaphore) SetMax(newMax int) Dynaphore {
-	s.max <- newMax
+	select {
+	case s.maxCh <- newMax:
+	case <-s.closedCh:
+	}
 	return s
 }
 
 func (s *dynaphore) Current() int {
-	return <-s.current
+	select {
+	case c := <-s.currentCh:
+		return c
+	case <-s.closedCh:
+		return 0
+	}
+}
+
+func (s *dynaphore) Max() int {
+	select {
+	case m := <-s.maxReadCh:
+		return m
+	case <-s.closedCh:
+		return 0
+	}
+}
+
+func (s *dynaphore) Waiters() int {
+	select {
+	case n := <-s.waitersCh:
+		return n
+	case <-s.closedCh:
+		return 0
+	}
+}
+
+func (s *dynaphore) SetStatsHook(hook StatsHook) Dynaphore {
+	select {
+	case s.statsHookCh <- hook:
+	case <-s.closedCh:
+	}
+	return s
+}
+
+func (s *dynaphore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closeCh)
+	})
+	<-s.closedCh
+	return nil
 }
 
 func (s *dynaphore) Up() Dynaphore {
-	s.lock <- struct{}{}
+	return s.UpN(1)
+}
+
+func (s *dynaphore) UpN(n int) Dynaphore {
+	_ = s.AcquireN(context.Background(), n)
 	return s
 }
 
 func (s *dynaphore) UpChan() LockChan {
-	l := make(chan struct{})
-	go func() {
-		s.lock <- struct{}{}
-		close(l)
-	}()
-	return l
+	w := &waiter{n: 1, ready: make(chan struct{})}
+	select {
+	case s.acquireCh <- w:
+		return w.ready
+	case <-s.closedCh:
+		closedLock := make(chan struct{})
+		close(closedLock)
+		return closedLock
+	}
 }
 
-func (s *dynaphore) DownChan(l LockChan) {
-	go func() {
-		<-l
-		s.Down()
-	}()
+func (s *dynaphore) DownChan(lockCh LockChan) {
+	select {
+	case s.downChanCh <- lockCh:
+	case <-s.closedCh:
+	}
 }
 
 func (s *dynaphore) Down() Dynaphore {
-	s.unlock <- struct{}{}
+	return s.DownN(1)
+}
+
+func (s *dynaphore) DownN(n int) Dynaphore {
+	select {
+	case s.downCh <- n:
+	case <-s.closedCh:
+	}
 	return s
 }
 
+func (s *dynaphore) Acquire(ctx context.Context) error {
+	return s.AcquireN(ctx, 1)
+}
+
+func (s *dynaphore) AcquireN(ctx context.Context, n int) error {
+	select {
+	case currentMax := <-s.maxReadCh:
+		if n > currentMax {
+			// n can never be satisfied; don't occupy a queue slot that would block other waiters.
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.closedCh:
+				return ErrClosed
+			}
+		}
+	case <-s.closedCh:
+		return ErrClosed
+	}
+
+	w := &waiter{n: n, ready: make(chan struct{})}
+	select {
+	case s.acquireCh <- w:
+	case <-s.closedCh:
+		return ErrClosed
+	}
+
+	select {
+	case <-w.ready:
+		// w.granted is only ever set before ready is closed, so observing ready closed makes it safe to read.
+		if !w.granted {
+			return ErrClosed
+		}
+		return nil
+	case <-s.closedCh:
+		return ErrClosed
+	case <-ctx.Done():
+		err := ctx.Err()
+		select {
+		case <-w.ready:
+			if w.granted {
+				// Granted right as we were canceling; honor the acquisition rather than discard the permit.
+				return nil
+			}
+			return ErrClosed
+		default:
+		}
+		select {
+		case s.cancelCh <- LockChan(w.ready):
+		case <-s.closedCh:
+			return ErrClosed
+		}
+		select {
+		case <-w.ready:
+			if w.granted {
+				// Granted right as we were canceling; honor the acquisition rather than discard the permit.
+				return nil
+			}
+			return ErrClosed
+		default:
+			return err
+		}
+	}
+}
+
+func (s *dynaphore) TryAcquire() bool {
+	req := &tryRequest{n: 1, result: make(chan bool)}
+	select {
+	case s.tryCh <- req:
+		return <-req.result
+	case <-s.closedCh:
+		return false
+	}
+}
+
 func (s *dynaphore) manager() {
 	current := 0
-	max := <-s.max
-	for {
-		lock := s.lock
-		if current >= max {
-			lock = nil // at or over max, block locks until we are under
+	max := <-s.maxCh
+	waiters := list.New()
+	pending := map[LockChan]*list.Element{}
+	var hook StatsHook
+
+	fire := func(event StatsEvent) {
+		if hook != nil {
+			hook(event, current, waiters.Len(), max)
 		}
+	}
+
+	admit := func() {
+		for {
+			front := waiters.Front()
+			if front == nil {
+				return
+			}
+			w := front.Value.(*waiter)
+			if current+w.n > max {
+				// Head-of-line blocking: don't skip this waiter to admit a smaller one behind it, or a steady
+				// stream of small acquisitions could starve a larger one indefinitely.
+				return
+			}
+			current += w.n
+			waiters.Remove(front)
+			delete(pending, LockChan(w.ready))
+			w.granted = true
+			close(w.ready)
+			fire(EventWaiterDequeue)
+			fire(EventAcquire)
+		}
+	}
+
+	for {
 		select {
-		case <-lock:
-			current++
-		case <-s.unlock:
-			if current > 0 { // this is to handle misbehaving users that call Down without having called Up first
+		case w := <-s.acquireCh:
+			if current+w.n <= max && waiters.Len() == 0 {
+				current += w.n
+				w.granted = true
+				close(w.ready)
+				fire(EventAcquire)
+			} else {
+				pending[LockChan(w.ready)] = waiters.PushBack(w)
+				fire(EventWaiterEnqueue)
+			}
+		case ch := <-s.cancelCh:
+			if elem, ok := pending[ch]; ok {
+				waiters.Remove(elem)
+				delete(pending, ch)
+				fire(EventWaiterDequeue)
+			}
+			// else: already granted; the caller observed this and keeps the permit, nothing to do here.
+		case ch := <-s.downChanCh:
+			if elem, ok := pending[ch]; ok {
+				waiters.Remove(elem)
+				delete(pending, ch)
+				fire(EventWaiterDequeue)
+			} else if current > 0 { // already granted; treat as a Down()
 				current--
+				fire(EventRelease)
+				admit()
+			}
+		case req := <-s.tryCh:
+			if current+req.n <= max && waiters.Len() == 0 {
+				current += req.n
+				req.result <- true
+				fire(EventAcquire)
+			} else {
+				req.result <- false
+			}
+		case n := <-s.downCh:
+			current -= n
+			if current < 0 { // this is to handle misbehaving users that release more than they acquired
+				current = 0
+			}
+			fire(EventRelease)
+			admit()
+		case s.currentCh <- current: // respond to a Current() call
+		case s.maxReadCh <- max: // respond to an internal max read
+		case s.waitersCh <- waiters.Len(): // respond to a Waiters() call
+		case hook = <-s.statsHookCh: // install a new StatsHook, then loop
+		case newMax := <-s.maxCh: // update max, then loop
+			max = newMax
+			fire(EventMaxChange)
+			admit()
+		case <-s.closeCh:
+			// Acquire/AcquireN callers also select on closedCh directly, but UpChan() callers only have the bare
+			// ready channel to watch, so close every outstanding waiter's ready channel to unblock them too.
+			for e := waiters.Front(); e != nil; {
+				next := e.Next()
+				w := e.Value.(*waiter)
+				waiters.Remove(e)
+				delete(pending, LockChan(w.ready))
+				close(w.ready)
+				fire(EventWaiterDequeue)
+				e = next
 			}
-		case s.current <- current: // respond to a Current() call
-		case max = <-s.max: //update max, then loop
+			close(s.closedCh)
+			return
 		}
 	}
 }
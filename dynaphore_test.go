@@ -1,6 +1,7 @@
 package dynaphore
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -149,6 +150,338 @@ func TestDynaphore_SetMax(t *testing.T) {
 	}
 }
 
+func TestDynaphore_Acquire(t *testing.T) {
+	dyn := NewDynaphore(1)
+
+	if err := dyn.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed unexpectedly: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := dyn.Acquire(ctx)
+	dur := time.Now().Sub(start)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if dur < 20*time.Millisecond {
+		t.Fatalf("Acquire returned before its context expired, after %v", dur)
+	}
+
+	// The failed Acquire should not have consumed a permit, nor left a stale waiter behind.
+	dyn.Down() // release the one lock we hold
+	if err := dyn.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire failed unexpectedly after cancellation: %v", err)
+	}
+}
+
+func TestDynaphore_Acquire_GrantedDuringCancel(t *testing.T) {
+	dyn := NewDynaphore(1)
+
+	dyn.Up() // 1, the only permit is now held
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dyn.Acquire(ctx)
+	}()
+
+	// Give the goroutine a moment to register its waiter, then race a Down() against a Cancel().
+	time.Sleep(5 * time.Millisecond)
+	dyn.Down()
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Expected Acquire to win the race and return nil, got %v", err)
+	}
+
+	if current := dyn.Current(); current != 1 {
+		t.Fatalf("Expected 1 active lock, got %d", current)
+	}
+}
+
+func TestDynaphore_TryAcquire(t *testing.T) {
+	dyn := NewDynaphore(1)
+
+	if !dyn.TryAcquire() {
+		t.Fatalf("First TryAcquire unexpectedly failed.")
+	}
+
+	if dyn.TryAcquire() {
+		t.Fatalf("Second TryAcquire unexpectedly succeeded.")
+	}
+
+	dyn.Down()
+
+	if !dyn.TryAcquire() {
+		t.Fatalf("TryAcquire after Down() unexpectedly failed.")
+	}
+}
+
+func TestDynaphore_UpN_DownN(t *testing.T) {
+	dyn := NewDynaphore(3)
+
+	dyn.UpN(2) // 2
+
+	if !dyn.TryAcquire() { // 3
+		t.Fatalf("TryAcquire unexpectedly failed with 1 permit still free.")
+	}
+
+	if dyn.TryAcquire() {
+		t.Fatalf("TryAcquire unexpectedly succeeded at max.")
+	}
+
+	dyn.DownN(2) // 1
+
+	if !dyn.TryAcquire() { // 2
+		t.Fatalf("TryAcquire unexpectedly failed after DownN(2) freed 2 permits.")
+	}
+}
+
+func TestDynaphore_AcquireN_RejectsOversizedRequest(t *testing.T) {
+	dyn := NewDynaphore(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := dyn.AcquireN(ctx, 3) // can never be satisfied, max is only 2
+	dur := time.Now().Sub(start)
+
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if dur < 20*time.Millisecond {
+		t.Fatalf("AcquireN returned before its context expired, after %v", dur)
+	}
+
+	// The oversized request should never have entered the queue, so ordinary acquisitions still work.
+	if !dyn.TryAcquire() {
+		t.Fatalf("TryAcquire unexpectedly failed after an oversized AcquireN gave up.")
+	}
+}
+
+func TestDynaphore_AcquireN_HeadOfLineFairness(t *testing.T) {
+	dyn := NewDynaphore(3)
+
+	dyn.UpN(3) // hold all 3 permits
+
+	bigDone := make(chan error, 1)
+	go func() {
+		bigDone <- dyn.AcquireN(context.Background(), 3)
+	}()
+
+	time.Sleep(5 * time.Millisecond) // let the big waiter enqueue first
+
+	dyn.DownN(1) // 2 held, 1 free: not enough for the big waiter
+	dyn.DownN(1) // 1 held, 2 free: still not enough
+
+	// A smaller request behind the big one in the queue should not jump ahead.
+	if dyn.TryAcquire() {
+		t.Fatalf("Small TryAcquire unexpectedly jumped ahead of a queued larger waiter.")
+	}
+
+	select {
+	case err := <-bigDone:
+		t.Fatalf("Big AcquireN unexpectedly completed early with err=%v", err)
+	default:
+	}
+
+	dyn.DownN(1) // 0 held, 3 free: now the big waiter fits
+
+	if err := <-bigDone; err != nil {
+		t.Fatalf("Big AcquireN failed unexpectedly: %v", err)
+	}
+	if current := dyn.Current(); current != 3 {
+		t.Fatalf("Expected 3 active locks after the big AcquireN was granted, got %d", current)
+	}
+}
+
+func TestDynaphore_Close(t *testing.T) {
+	dyn := NewDynaphore(1)
+
+	dyn.Up() // 1, hold the only permit
+
+	done := make(chan error, 1)
+	go func() {
+		done <- dyn.Acquire(context.Background())
+	}()
+
+	time.Sleep(5 * time.Millisecond) // let the goroutine enqueue its waiter
+
+	if err := dyn.Close(); err != nil {
+		t.Fatalf("Close failed unexpectedly: %v", err)
+	}
+
+	select {
+	case err := <-done:
+		if err != ErrClosed {
+			t.Fatalf("Expected pending Acquire to be unblocked with ErrClosed, got %v", err)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Pending Acquire was not unblocked by Close.")
+	}
+
+	if err := dyn.Acquire(context.Background()); err != ErrClosed {
+		t.Fatalf("Expected Acquire on a closed Dynaphore to return ErrClosed, got %v", err)
+	}
+
+	if dyn.TryAcquire() {
+		t.Fatalf("TryAcquire unexpectedly succeeded on a closed Dynaphore.")
+	}
+
+	// SetMax and Down(N) should be tolerated no-ops, not hang, after Close.
+	dyn.SetMax(5)
+	dyn.Down()
+
+	// Close is idempotent.
+	if err := dyn.Close(); err != nil {
+		t.Fatalf("Second Close call failed unexpectedly: %v", err)
+	}
+}
+
+func TestDynaphore_Close_UnblocksUpChan(t *testing.T) {
+	dyn := NewDynaphore(0) // max of 0 means the lock can never be granted normally
+
+	lockCh := dyn.UpChan()
+
+	done := make(chan struct{})
+	go func() {
+		<-lockCh
+		close(done)
+	}()
+
+	time.Sleep(5 * time.Millisecond) // let the goroutine enqueue its waiter
+
+	if err := dyn.Close(); err != nil {
+		t.Fatalf("Close failed unexpectedly: %v", err)
+	}
+
+	select {
+	case <-done:
+		// lockCh was closed as expected, unblocking the waiting goroutine.
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Pending UpChan waiter was not unblocked by Close.")
+	}
+}
+
+func TestDynaphore_MaxAndWaiters(t *testing.T) {
+	dyn := NewDynaphore(2)
+
+	if max := dyn.Max(); max != 2 {
+		t.Fatalf("Expected Max() to be 2, got %d", max)
+	}
+
+	dyn.Up() // 1
+	dyn.Up() // 2
+
+	if waiters := dyn.Waiters(); waiters != 0 {
+		t.Fatalf("Expected 0 waiters, got %d", waiters)
+	}
+
+	go dyn.Up() // blocks, queued
+
+	time.Sleep(5 * time.Millisecond) // let the goroutine enqueue
+	if waiters := dyn.Waiters(); waiters != 1 {
+		t.Fatalf("Expected 1 waiter, got %d", waiters)
+	}
+
+	dyn.SetMax(5)
+	time.Sleep(5 * time.Millisecond)
+
+	if max := dyn.Max(); max != 5 {
+		t.Fatalf("Expected Max() to be 5 after SetMax, got %d", max)
+	}
+	if waiters := dyn.Waiters(); waiters != 0 {
+		t.Fatalf("Expected 0 waiters after SetMax freed the queued waiter, got %d", waiters)
+	}
+}
+
+func TestDynaphore_StatsHook(t *testing.T) {
+	dyn := NewDynaphore(1)
+
+	type call struct {
+		event                 StatsEvent
+		current, waiters, max int
+	}
+	calls := make(chan call, 16)
+
+	dyn.SetStatsHook(func(event StatsEvent, current, waiters, max int) {
+		calls <- call{event, current, waiters, max}
+	})
+
+	dyn.Up() // 1
+
+	select {
+	case c := <-calls:
+		if c.event != EventAcquire || c.current != 1 {
+			t.Fatalf("Expected EventAcquire with current=1, got %+v", c)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for Up(), got none.")
+	}
+
+	dyn.Down() // 0
+
+	select {
+	case c := <-calls:
+		if c.event != EventRelease || c.current != 0 {
+			t.Fatalf("Expected EventRelease with current=0, got %+v", c)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for Down(), got none.")
+	}
+
+	dyn.SetMax(3)
+
+	select {
+	case c := <-calls:
+		if c.event != EventMaxChange || c.max != 3 {
+			t.Fatalf("Expected EventMaxChange with max=3, got %+v", c)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for SetMax(), got none.")
+	}
+
+	dyn.Up() // 1, so the AcquireN(3) below can't complete immediately and has to queue
+
+	select {
+	case <-calls:
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for Up(), got none.")
+	}
+
+	go dyn.AcquireN(context.Background(), 3)
+
+	time.Sleep(5 * time.Millisecond) // let the goroutine enqueue its waiter
+
+	select {
+	case c := <-calls:
+		if c.event != EventWaiterEnqueue || c.waiters != 1 {
+			t.Fatalf("Expected EventWaiterEnqueue with waiters=1, got %+v", c)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for the queued AcquireN, got none.")
+	}
+
+	dyn.Close()
+
+	// Close must dequeue the still-pending waiter through the same fire() path as a normal cancel, so consumers
+	// of the hook see the waiter count drop back to zero rather than staying stale after shutdown.
+	select {
+	case c := <-calls:
+		if c.event != EventWaiterDequeue || c.waiters != 0 {
+			t.Fatalf("Expected EventWaiterDequeue with waiters=0 for the waiter Close() unblocked, got %+v", c)
+		}
+	case <-time.After(50 * time.Millisecond):
+		t.Fatalf("Expected a StatsHook call for the waiter Close() unblocked, got none.")
+	}
+}
+
 func TestDynaphore_Defer(t *testing.T) {
 	dyn := NewDynaphore(1)
 
@@ -159,8 +492,8 @@ func TestDynaphore_Defer(t *testing.T) {
 	go func() {
 		defer close(finished) // defers are run first-in last-out, so this happens *after* Down() completes
 		defer dyn.Up().Down() // should acquire a lock, but not release it until this function returns
-		close(started) // let the main routine know we've got the lock
-		<-finish // wait until the main routine tells us to finish
+		close(started)        // let the main routine know we've got the lock
+		<-finish              // wait until the main routine tells us to finish
 	}()
 
 	<-started // wait for the goroutine to indicate it has the lock
@@ -185,4 +518,4 @@ func TestDynaphore_Defer(t *testing.T) {
 	default: // channel not closed, fail
 		t.Fatalf("Lock could not be acquired.")
 	}
-}
\ No newline at end of file
+}